@@ -0,0 +1,153 @@
+package nsmd
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+
+	"github.com/networkservicemesh/networkservicemesh/controlplane/pkg/apis/registry"
+	"github.com/networkservicemesh/networkservicemesh/pkg/tools/security"
+)
+
+// testBundleCA is a self-signed CA that issueTestBundle signs leaf
+// certificates with, so bundles issued from the same one trust each other.
+type testBundleCA struct {
+	cert *x509.Certificate
+	key  *rsa.PrivateKey
+	der  []byte
+}
+
+func newTestBundleCA(t *testing.T) *testBundleCA {
+	g := NewWithT(t)
+
+	caKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	g.Expect(err).ToNot(HaveOccurred())
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "nsmd-test-ca"},
+		NotBefore:             time.Unix(0, 0),
+		NotAfter:              time.Unix(0, 0).Add(100 * 365 * 24 * time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	g.Expect(err).ToNot(HaveOccurred())
+	caCert, err := x509.ParseCertificate(caDER)
+	g.Expect(err).ToNot(HaveOccurred())
+	return &testBundleCA{cert: caCert, key: caKey, der: caDER}
+}
+
+// issueTestBundle writes ca's certificate plus a leaf certificate signed by
+// ca, carrying spiffeID as a URI SAN and 127.0.0.1 as an IP SAN, into dir, in
+// the tls.crt/tls.key/ca.crt layout security.Manager expects.
+func issueTestBundle(t *testing.T, ca *testBundleCA, dir, spiffeID string) {
+	g := NewWithT(t)
+
+	leafKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	g.Expect(err).ToNot(HaveOccurred())
+	uri, err := url.Parse(spiffeID)
+	g.Expect(err).ToNot(HaveOccurred())
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: spiffeID},
+		NotBefore:    time.Unix(0, 0),
+		NotAfter:     time.Unix(0, 0).Add(100 * 365 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		URIs:         []*url.URL{uri},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, ca.cert, &leafKey.PublicKey, ca.key)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(os.MkdirAll(dir, 0755)).To(Succeed())
+	g.Expect(ioutil.WriteFile(dir+"/tls.crt", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER}), 0644)).To(Succeed())
+	g.Expect(ioutil.WriteFile(dir+"/tls.key", pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(leafKey)}), 0644)).To(Succeed())
+	g.Expect(ioutil.WriteFile(dir+"/ca.crt", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.der}), 0644)).To(Succeed())
+}
+
+// TestStreamAuthorizeRemoteNSMInterceptor asserts that the interceptor
+// admits a call from the expected NSM's identity and rejects one from any
+// other, exercising AuthorizeRemoteNSM itself rather than a stand-in for it.
+// It uses the stream interceptor (rather than the unary one) because a
+// server with no registered services answers every call, including unary
+// ones, through its UnknownServiceHandler as a stream.
+func TestStreamAuthorizeRemoteNSMInterceptor(t *testing.T) {
+	g := NewWithT(t)
+
+	ca := newTestBundleCA(t)
+	serverDir := t.TempDir()
+	issueTestBundle(t, ca, serverDir, "spiffe://networkservicemesh.io/nsm/server")
+	serverMgr, err := security.NewManager(security.ModeMTLS, serverDir)
+	g.Expect(err).ToNot(HaveOccurred())
+	defer serverMgr.Close()
+
+	tests := []struct {
+		name        string
+		clientSAN   string
+		expectedNSM string
+		wantErr     bool
+	}{
+		{name: "matching identity is admitted", clientSAN: "spiffe://networkservicemesh.io/nsm/peer", expectedNSM: "spiffe://networkservicemesh.io/nsm/peer", wantErr: false},
+		{name: "mismatched identity is rejected", clientSAN: "spiffe://networkservicemesh.io/nsm/impostor", expectedNSM: "spiffe://networkservicemesh.io/nsm/peer", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			clientDir := t.TempDir()
+			issueTestBundle(t, ca, clientDir, tt.clientSAN)
+			clientMgr, err := security.NewManager(security.ModeMTLS, clientDir)
+			g.Expect(err).ToNot(HaveOccurred())
+			defer clientMgr.Close()
+
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			g.Expect(err).ToNot(HaveOccurred())
+
+			expected := &registry.NetworkServiceManager{Name: tt.expectedNSM}
+			handlerErrCh := make(chan error, 1)
+			srv := grpc.NewServer(
+				serverMgr.ServerCreds(),
+				grpc.StreamInterceptor(StreamAuthorizeRemoteNSMInterceptor(expected)),
+				grpc.UnknownServiceHandler(func(_ interface{}, stream grpc.ServerStream) error {
+					handlerErrCh <- nil
+					return nil
+				}),
+			)
+			go srv.Serve(lis)
+			defer srv.Stop()
+
+			conn, err := grpc.Dial(lis.Addr().String(), clientMgr.DialOption())
+			g.Expect(err).ToNot(HaveOccurred())
+			defer conn.Close()
+
+			invokeErr := conn.Invoke(context.Background(), "/nsm.Test/Ping", struct{}{}, &struct{}{})
+
+			if tt.wantErr {
+				g.Expect(invokeErr).To(HaveOccurred())
+				return
+			}
+			select {
+			case herr := <-handlerErrCh:
+				g.Expect(herr).ToNot(HaveOccurred())
+			case <-time.After(5 * time.Second):
+				t.Fatal("nsmd: handler never ran behind the authorize interceptor")
+			}
+		})
+	}
+}
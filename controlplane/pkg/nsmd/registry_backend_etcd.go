@@ -0,0 +1,454 @@
+package nsmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/empty"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+
+	"github.com/networkservicemesh/networkservicemesh/controlplane/pkg/apis/registry"
+	"github.com/networkservicemesh/networkservicemesh/controlplane/pkg/serviceregistry"
+)
+
+const (
+	// EtcdRegistryPrefixEnv overrides the key prefix the etcd registry
+	// backend stores NSM/NSE endpoints under.
+	EtcdRegistryPrefixEnv = "NSM_REGISTRY_ETCD_PREFIX"
+	// EtcdRegistryPrefixDefault is used when EtcdRegistryPrefixEnv is unset.
+	EtcdRegistryPrefixDefault = "/networkservicemesh"
+	// etcdLeaseTTLSeconds bounds how long an nsmd's endpoints survive after
+	// it stops renewing its lease (crash, network partition).
+	etcdLeaseTTLSeconds = 10
+)
+
+// etcdEnvelope is the JSON value stored under each endpoint key. The proto
+// message itself is kept binary-marshaled so the envelope stays agnostic of
+// which of NSM/NSE it carries.
+type etcdEnvelope struct {
+	Kind serviceregistry.EndpointKind `json:"kind"`
+	NSM  []byte                       `json:"nsm,omitempty"`
+	NSE  []byte                       `json:"nse,omitempty"`
+}
+
+func nsmEtcdKey(prefix, name string) string {
+	return fmt.Sprintf("%s/nsm/%s", prefix, name)
+}
+
+func nseEtcdKey(prefix, service, name string) string {
+	return fmt.Sprintf("%s/nse/%s/%s", prefix, service, name)
+}
+
+// etcdBackend is a serviceregistry.Backend that models every NSM/NSE as a
+// keyed entry in etcd, bound to a lease that is renewed for as long as this
+// process is alive. Discovery is push-based: callers Watch a key prefix
+// instead of pulling from a remote nsm-registry on every lookup.
+type etcdBackend struct {
+	client  *clientv3.Client
+	prefix  string
+	leaseID clientv3.LeaseID
+	stopCh  chan struct{}
+}
+
+func newEtcdBackend(endpoints []string, prefix string) (*etcdBackend, error) {
+	return newEtcdBackendWithTTL(endpoints, prefix, etcdLeaseTTLSeconds)
+}
+
+// newEtcdBackendWithTTL is split out of newEtcdBackend so tests can exercise
+// lease expiry without waiting out the production TTL.
+func newEtcdBackendWithTTL(endpoints []string, prefix string, ttlSeconds int64) (*etcdBackend, error) {
+	cli, err := clientv3.New(clientv3.Config{
+		Endpoints:   endpoints,
+		DialTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("etcd registry: failed to connect to %v: %v", endpoints, err)
+	}
+
+	lease, err := cli.Grant(context.Background(), ttlSeconds)
+	if err != nil {
+		cli.Close()
+		return nil, fmt.Errorf("etcd registry: failed to create lease: %v", err)
+	}
+
+	keepAlive, err := cli.KeepAlive(context.Background(), lease.ID)
+	if err != nil {
+		cli.Close()
+		return nil, fmt.Errorf("etcd registry: failed to start lease keepalive: %v", err)
+	}
+
+	b := &etcdBackend{
+		client:  cli,
+		prefix:  prefix,
+		leaseID: lease.ID,
+		stopCh:  make(chan struct{}),
+	}
+	go b.drainKeepAlive(keepAlive)
+	return b, nil
+}
+
+// drainKeepAlive consumes the lease keepalive channel so etcd's client
+// library keeps renewing it; once the channel closes (client shutdown, lease
+// revoked) nsmd's own entries will expire and disappear from peers' watches.
+func (b *etcdBackend) drainKeepAlive(keepAlive <-chan *clientv3.LeaseKeepAliveResponse) {
+	for {
+		select {
+		case _, ok := <-keepAlive:
+			if !ok {
+				logrus.Warn("etcd registry: lease keepalive stopped, local endpoints will expire")
+				return
+			}
+		case <-b.stopCh:
+			return
+		}
+	}
+}
+
+func (b *etcdBackend) marshalEndpoint(endpoint *serviceregistry.Endpoint) (string, error) {
+	env := etcdEnvelope{Kind: endpoint.Kind}
+	var err error
+	switch endpoint.Kind {
+	case serviceregistry.NSMEndpoint:
+		if env.NSM, err = proto.Marshal(endpoint.NSM); err != nil {
+			return "", err
+		}
+	case serviceregistry.NSEEndpoint:
+		if env.NSE, err = proto.Marshal(endpoint.NSE); err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("etcd registry: unknown endpoint kind %q", endpoint.Kind)
+	}
+	value, err := json.Marshal(env)
+	if err != nil {
+		return "", err
+	}
+	return string(value), nil
+}
+
+func (b *etcdBackend) put(ctx context.Context, endpoint *serviceregistry.Endpoint) error {
+	value, err := b.marshalEndpoint(endpoint)
+	if err != nil {
+		return err
+	}
+	_, err = b.client.Put(ctx, endpoint.Key, value, clientv3.WithLease(b.leaseID))
+	return err
+}
+
+func (b *etcdBackend) Add(ctx context.Context, endpoint *serviceregistry.Endpoint) error {
+	return b.put(ctx, endpoint)
+}
+
+func (b *etcdBackend) Update(ctx context.Context, endpoint *serviceregistry.Endpoint) error {
+	return b.put(ctx, endpoint)
+}
+
+func (b *etcdBackend) Delete(ctx context.Context, key string) error {
+	_, err := b.client.Delete(ctx, key)
+	return err
+}
+
+func decodeEtcdEndpoint(key string, value []byte) (*serviceregistry.Endpoint, error) {
+	var env etcdEnvelope
+	if err := json.Unmarshal(value, &env); err != nil {
+		return nil, err
+	}
+	ep := &serviceregistry.Endpoint{Kind: env.Kind, Key: key}
+	switch env.Kind {
+	case serviceregistry.NSMEndpoint:
+		ep.NSM = &registry.NetworkServiceManager{}
+		if err := proto.Unmarshal(env.NSM, ep.NSM); err != nil {
+			return nil, err
+		}
+	case serviceregistry.NSEEndpoint:
+		ep.NSE = &registry.NSERegistration{}
+		if err := proto.Unmarshal(env.NSE, ep.NSE); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("etcd registry: unknown endpoint kind %q for key %s", env.Kind, key)
+	}
+	return ep, nil
+}
+
+// Watch lists the current entries under prefix and then streams their
+// changes. The initial list is sent from the same background goroutine that
+// serves the watch, not before out is returned, so a prefix with more
+// entries than out's buffer can hold doesn't deadlock waiting for a reader
+// that can't arrive until Watch returns. If etcd reports the watch revision
+// was compacted away, Watch transparently re-lists from the latest revision
+// and keeps going, so callers never see a "watch died" event.
+func (b *etcdBackend) Watch(ctx context.Context, prefix string) (<-chan serviceregistry.Event, error) {
+	out := make(chan serviceregistry.Event, 16)
+
+	// send delivers ev on out, but gives up instead of blocking forever if
+	// out's reader is gone and ctx/stopCh fire first; it reports whether the
+	// caller should keep going.
+	send := func(ev serviceregistry.Event) bool {
+		select {
+		case out <- ev:
+			return true
+		case <-ctx.Done():
+			return false
+		case <-b.stopCh:
+			return false
+		}
+	}
+
+	listAndWatch := func() (clientv3.WatchChan, error) {
+		resp, err := b.client.Get(ctx, prefix, clientv3.WithPrefix())
+		if err != nil {
+			return nil, err
+		}
+		for _, kv := range resp.Kvs {
+			ep, err := decodeEtcdEndpoint(string(kv.Key), kv.Value)
+			if err != nil {
+				logrus.Errorf("etcd registry: failed to decode %s: %v", kv.Key, err)
+				continue
+			}
+			send(serviceregistry.Event{Type: serviceregistry.EventAdd, Endpoint: ep})
+		}
+		return b.client.Watch(ctx, prefix, clientv3.WithPrefix(), clientv3.WithRev(resp.Header.Revision+1)), nil
+	}
+
+	// A first Get is issued here, synchronously, only to surface a dial/auth
+	// error to the caller of Watch immediately; it does not send on out, so
+	// it can't block regardless of how many entries exist under prefix.
+	if _, err := b.client.Get(ctx, prefix, clientv3.WithPrefix(), clientv3.WithCountOnly()); err != nil {
+		close(out)
+		return nil, err
+	}
+
+	go func() {
+		defer close(out)
+		watchCh, err := listAndWatch()
+		if err != nil {
+			logrus.Errorf("etcd registry: initial list of %s failed: %v", prefix, err)
+			return
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-b.stopCh:
+				return
+			case resp, ok := <-watchCh:
+				if !ok {
+					return
+				}
+				if err := resp.Err(); err != nil {
+					logrus.Warnf("etcd registry: watch on %s failed (%v), re-listing", prefix, err)
+					if watchCh, err = listAndWatch(); err != nil {
+						logrus.Errorf("etcd registry: re-list of %s failed: %v", prefix, err)
+						return
+					}
+					continue
+				}
+				for _, ev := range resp.Events {
+					evType := serviceregistry.EventUpdate
+					switch {
+					case ev.Type == clientv3.EventTypeDelete:
+						evType = serviceregistry.EventDelete
+					case ev.IsCreate():
+						evType = serviceregistry.EventAdd
+					}
+					if evType == serviceregistry.EventDelete {
+						if !send(serviceregistry.Event{Type: evType, Endpoint: &serviceregistry.Endpoint{Key: string(ev.Kv.Key)}}) {
+							return
+						}
+						continue
+					}
+					ep, err := decodeEtcdEndpoint(string(ev.Kv.Key), ev.Kv.Value)
+					if err != nil {
+						logrus.Errorf("etcd registry: failed to decode %s: %v", ev.Kv.Key, err)
+						continue
+					}
+					if !send(serviceregistry.Event{Type: evType, Endpoint: ep}) {
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func (b *etcdBackend) Close() error {
+	close(b.stopCh)
+	_, _ = b.client.Revoke(context.Background(), b.leaseID)
+	return b.client.Close()
+}
+
+// etcdRegistryCache mirrors the NSM/NSE entries published under a prefix by
+// consuming Backend.Watch, so FindNetworkService/discovery lookups are
+// answered locally instead of round-tripping to a remote nsm-registry.
+type etcdRegistryCache struct {
+	sync.RWMutex
+	nsmByName map[string]*registry.NetworkServiceManager
+	nseByKey  map[string]*registry.NSERegistration
+}
+
+// nseCacheKey scopes an NSE cache entry by its owning service as well as its
+// endpoint name, matching the "/nse/<service>/<name>" etcd key scheme so
+// same-named endpoints registered under different services don't collide.
+func nseCacheKey(service, name string) string {
+	return service + "/" + name
+}
+
+func newEtcdRegistryCache(ctx context.Context, backend *etcdBackend, prefix string) (*etcdRegistryCache, error) {
+	events, err := backend.Watch(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	cache := &etcdRegistryCache{
+		nsmByName: map[string]*registry.NetworkServiceManager{},
+		nseByKey:  map[string]*registry.NSERegistration{},
+	}
+	go cache.consume(events)
+	return cache, nil
+}
+
+func (c *etcdRegistryCache) consume(events <-chan serviceregistry.Event) {
+	for ev := range events {
+		c.Lock()
+		switch ev.Type {
+		case serviceregistry.EventAdd, serviceregistry.EventUpdate:
+			switch ev.Endpoint.Kind {
+			case serviceregistry.NSMEndpoint:
+				c.nsmByName[ev.Endpoint.NSM.GetName()] = ev.Endpoint.NSM
+			case serviceregistry.NSEEndpoint:
+				ep := ev.Endpoint.NSE.GetNetworkServiceEndpoint()
+				c.nseByKey[nseCacheKey(ep.GetNetworkServiceName(), ep.GetEndpointName())] = ev.Endpoint.NSE
+			}
+		case serviceregistry.EventDelete:
+			c.forgetKey(ev.Endpoint.Key)
+		}
+		c.Unlock()
+	}
+}
+
+// forgetKey drops whichever cached entry was stored under key. Must be
+// called with the write lock held.
+func (c *etcdRegistryCache) forgetKey(key string) {
+	for name := range c.nsmByName {
+		if strings.HasSuffix(key, "/nsm/"+name) {
+			delete(c.nsmByName, name)
+			return
+		}
+	}
+	for cacheKey := range c.nseByKey {
+		if strings.HasSuffix(key, "/nse/"+cacheKey) {
+			delete(c.nseByKey, cacheKey)
+			return
+		}
+	}
+}
+
+func (c *etcdRegistryCache) endpointsForService(service string) []*registry.NSERegistration {
+	c.RLock()
+	defer c.RUnlock()
+	var result []*registry.NSERegistration
+	for _, nse := range c.nseByKey {
+		if nse.GetNetworkServiceEndpoint().GetNetworkServiceName() == service {
+			result = append(result, nse)
+		}
+	}
+	return result
+}
+
+// etcdRegistryClient implements registry.NetworkServiceRegistryClient on top
+// of an etcdBackend, publishing this nsmd's own NSM/NSE entries.
+type etcdRegistryClient struct {
+	backend *etcdBackend
+	prefix  string
+
+	mu            sync.Mutex
+	nseKeysByName map[string]string // endpoint name -> full etcd key, for the NSEs this client itself registered
+}
+
+func (c *etcdRegistryClient) RegisterNSM(ctx context.Context, nsm *registry.NetworkServiceManager, opts ...grpc.CallOption) (*registry.NetworkServiceManager, error) {
+	err := c.backend.Add(ctx, &serviceregistry.Endpoint{
+		Kind: serviceregistry.NSMEndpoint,
+		Key:  nsmEtcdKey(c.prefix, nsm.GetName()),
+		NSM:  nsm,
+	})
+	return nsm, err
+}
+
+func (c *etcdRegistryClient) RegisterNSE(ctx context.Context, nse *registry.NSERegistration, opts ...grpc.CallOption) (*registry.NSERegistration, error) {
+	ep := nse.GetNetworkServiceEndpoint()
+	key := nseEtcdKey(c.prefix, ep.GetNetworkServiceName(), ep.GetEndpointName())
+	if err := c.backend.Add(ctx, &serviceregistry.Endpoint{
+		Kind: serviceregistry.NSEEndpoint,
+		Key:  key,
+		NSE:  nse,
+	}); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.nseKeysByName[ep.GetEndpointName()] = key
+	c.mu.Unlock()
+	return nse, nil
+}
+
+// RemoveNSE deletes the etcd entry for req's endpoint name. RemoveNSERequest
+// carries only the endpoint name, not its owning service, so the same
+// endpoint name registered under two different services would otherwise be
+// ambiguous; to avoid deleting an unrelated service's registration, this
+// first resolves the name via nseKeysByName, the exact key this client used
+// when it called RegisterNSE. Only when the name was never registered
+// through this client (e.g. after a restart) does it fall back to scanning
+// the nse prefix, and it refuses to delete anything if that scan finds more
+// than one match rather than guessing which one was meant.
+func (c *etcdRegistryClient) RemoveNSE(ctx context.Context, req *registry.RemoveNSERequest, opts ...grpc.CallOption) (*empty.Empty, error) {
+	name := req.GetNetworkServiceEndpointName()
+
+	c.mu.Lock()
+	key, tracked := c.nseKeysByName[name]
+	delete(c.nseKeysByName, name)
+	c.mu.Unlock()
+
+	if tracked {
+		return &empty.Empty{}, c.backend.Delete(ctx, key)
+	}
+
+	resp, err := c.backend.client.Get(ctx, c.prefix+"/nse/", clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	var matches []string
+	for _, kv := range resp.Kvs {
+		if strings.HasSuffix(string(kv.Key), "/"+name) {
+			matches = append(matches, string(kv.Key))
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return &empty.Empty{}, nil
+	case 1:
+		return &empty.Empty{}, c.backend.Delete(ctx, matches[0])
+	default:
+		return nil, fmt.Errorf("etcd registry: %d entries match endpoint name %q across different services, refusing to guess which to remove", len(matches), name)
+	}
+}
+
+// etcdDiscoveryClient implements registry.NetworkServiceDiscoveryClient on
+// top of the shared etcdRegistryCache populated by the etcd Backend's Watch.
+type etcdDiscoveryClient struct {
+	cache *etcdRegistryCache
+}
+
+func (c *etcdDiscoveryClient) FindNetworkService(_ context.Context, req *registry.FindNetworkServiceRequest, opts ...grpc.CallOption) (*registry.FindNetworkServiceResponse, error) {
+	return &registry.FindNetworkServiceResponse{
+		NetworkServiceEndpoints: c.cache.endpointsForService(req.GetNetworkServiceName()),
+	}, nil
+}
@@ -0,0 +1,175 @@
+package nsmd
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/coreos/etcd/integration"
+	. "github.com/onsi/gomega"
+
+	"github.com/networkservicemesh/networkservicemesh/controlplane/pkg/apis/registry"
+	"github.com/networkservicemesh/networkservicemesh/controlplane/pkg/serviceregistry"
+)
+
+func newTestCluster(t *testing.T, size int) *integration.ClusterV3 {
+	return integration.NewClusterV3(t, &integration.ClusterConfig{Size: size})
+}
+
+func waitForEvent(t *testing.T, events <-chan serviceregistry.Event, want serviceregistry.EventType, timeout time.Duration) *serviceregistry.Event {
+	deadline := time.After(timeout)
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				t.Fatalf("registry: watch channel closed before a %v event arrived", want)
+			}
+			if ev.Type == want {
+				return &ev
+			}
+		case <-deadline:
+			t.Fatalf("registry: timed out waiting for a %v event", want)
+		}
+	}
+}
+
+// TestEtcdBackend_SplitBrain asserts that of two nsmds talking to two
+// different etcd members of the same cluster, the one watching (B) observes
+// the endpoint the other (A) added while its member was partitioned off, once
+// the partition heals.
+func TestEtcdBackend_SplitBrain(t *testing.T) {
+	g := NewWithT(t)
+	cluster := newTestCluster(t, 3)
+	defer cluster.Terminate(t)
+
+	prefix := "/networkservicemesh-test"
+
+	backendA, err := newEtcdBackend([]string{cluster.Members[0].GRPCAddr()}, prefix)
+	g.Expect(err).ToNot(HaveOccurred())
+	defer backendA.Close()
+
+	backendB, err := newEtcdBackend([]string{cluster.Members[1].GRPCAddr()}, prefix)
+	g.Expect(err).ToNot(HaveOccurred())
+	defer backendB.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	eventsOnB, err := backendB.Watch(ctx, prefix)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	// Isolate member 0 (where backendA writes) from the rest of the cluster,
+	// then heal the partition before asserting convergence.
+	cluster.Members[0].InjectPartition(t, cluster.Members[1:]...)
+
+	err = backendA.Add(ctx, &serviceregistry.Endpoint{
+		Kind: serviceregistry.NSMEndpoint,
+		Key:  nsmEtcdKey(prefix, "nsm-during-partition"),
+		NSM:  &registry.NetworkServiceManager{Name: "nsm-during-partition", Url: "127.0.0.1:6000"},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+
+	cluster.Members[0].RecoverPartition(t, cluster.Members[1:]...)
+
+	ev := waitForEvent(t, eventsOnB, serviceregistry.EventAdd, 10*time.Second)
+	g.Expect(ev.Endpoint.NSM.GetName()).To(Equal("nsm-during-partition"))
+}
+
+// TestEtcdBackend_WatchDoesNotBlockOnLargePrefix asserts that Watch returns
+// promptly even when more entries exist under prefix than fit in its
+// internal event buffer, and that every one of them is still delivered.
+// Regression test for a bug where the initial list was sent before Watch's
+// caller could start reading, deadlocking once the entry count exceeded the
+// channel's buffer size.
+func TestEtcdBackend_WatchDoesNotBlockOnLargePrefix(t *testing.T) {
+	g := NewWithT(t)
+	cluster := newTestCluster(t, 1)
+	defer cluster.Terminate(t)
+
+	prefix := "/networkservicemesh-test"
+	backend, err := newEtcdBackend([]string{cluster.Members[0].GRPCAddr()}, prefix)
+	g.Expect(err).ToNot(HaveOccurred())
+	defer backend.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const entryCount = 32 // more than out's buffer size of 16
+	for i := 0; i < entryCount; i++ {
+		name := fmt.Sprintf("nsm-%d", i)
+		err := backend.Add(ctx, &serviceregistry.Endpoint{
+			Kind: serviceregistry.NSMEndpoint,
+			Key:  nsmEtcdKey(prefix, name),
+			NSM:  &registry.NetworkServiceManager{Name: name, Url: "127.0.0.1:6000"},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+
+	watchStarted := make(chan struct{})
+	go func() {
+		defer close(watchStarted)
+		events, err := backend.Watch(ctx, prefix)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		seen := map[string]bool{}
+		for len(seen) < entryCount {
+			ev := waitForEvent(t, events, serviceregistry.EventAdd, 10*time.Second)
+			seen[ev.Endpoint.NSM.GetName()] = true
+		}
+	}()
+
+	select {
+	case <-watchStarted:
+	case <-time.After(15 * time.Second):
+		t.Fatal("registry: Watch did not deliver all entries under a large prefix in time")
+	}
+}
+
+// TestEtcdBackend_LeaseExpiry asserts that an endpoint disappears from
+// watchers once its owning nsmd stops renewing its lease, without an
+// explicit Delete call.
+func TestEtcdBackend_LeaseExpiry(t *testing.T) {
+	g := NewWithT(t)
+	cluster := newTestCluster(t, 1)
+	defer cluster.Terminate(t)
+
+	prefix := "/networkservicemesh-test"
+	addr := cluster.Members[0].GRPCAddr()
+
+	dying, err := newEtcdBackendWithTTL([]string{addr}, prefix, 1)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	watcher, err := newEtcdBackend([]string{addr}, prefix)
+	g.Expect(err).ToNot(HaveOccurred())
+	defer watcher.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := watcher.Watch(ctx, prefix)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	key := nseEtcdKey(prefix, "icmp-responder", "nse-1")
+	err = dying.Add(ctx, &serviceregistry.Endpoint{
+		Kind: serviceregistry.NSEEndpoint,
+		Key:  key,
+		NSE: &registry.NSERegistration{
+			NetworkServiceEndpoint: &registry.NetworkServiceEndpoint{
+				NetworkServiceName: "icmp-responder",
+				EndpointName:       "nse-1",
+			},
+		},
+	})
+	g.Expect(err).ToNot(HaveOccurred())
+	waitForEvent(t, events, serviceregistry.EventAdd, 5*time.Second)
+
+	// Simulate a crash: close the client without revoking the lease, so it
+	// can only go away once the TTL elapses.
+	dying.stopCh = make(chan struct{})
+	close(dying.stopCh)
+	_ = dying.client.Close()
+
+	ev := waitForEvent(t, events, serviceregistry.EventDelete, 10*time.Second)
+	g.Expect(ev.Endpoint.Key).To(Equal(key))
+}
@@ -0,0 +1,228 @@
+package nsmd
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	remote_networkservice "github.com/networkservicemesh/networkservicemesh/controlplane/pkg/apis/remote/networkservice"
+)
+
+const (
+	// remoteConnHealthCheckInterval bounds how often a cached peer
+	// connection is health-checked.
+	remoteConnHealthCheckInterval = 30 * time.Second
+	// remoteConnIdleTTL is how long an unreferenced cached connection is
+	// kept around before it is closed.
+	remoteConnIdleTTL = 1 * time.Minute
+)
+
+// RemoteConnectionRelease returns a leased remote connection to its cache.
+// Callers must invoke it exactly once, when they are done issuing RPCs
+// through the client handed back alongside it.
+type RemoteConnectionRelease func()
+
+type remoteConnEntry struct {
+	conn   *grpc.ClientConn
+	cancel context.CancelFunc
+
+	mu        sync.Mutex
+	refcount  int
+	idleSince time.Time
+}
+
+// RemoteConnectionCache maintains a single *grpc.ClientConn per peer NSM URL
+// instead of dialing fresh on every RemoteNetworkServiceClient call. A
+// cached connection is evicted, and the next Get redials, when: its state
+// settles into TransientFailure/Shutdown, a periodic gRPC health check
+// fails, or it has sat unreferenced for longer than the idle TTL. Concurrent
+// first dials to the same URL are serialized with a singleflight group so
+// only one of them actually dials.
+type RemoteConnectionCache struct {
+	dial              func(url string) (*grpc.ClientConn, error)
+	healthCheckPeriod time.Duration
+	idleTTL           time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*remoteConnEntry
+	group   singleflight.Group
+}
+
+// NewRemoteConnectionCache builds a RemoteConnectionCache that dials new
+// connections via dial.
+func NewRemoteConnectionCache(dial func(url string) (*grpc.ClientConn, error)) *RemoteConnectionCache {
+	return &RemoteConnectionCache{
+		dial:              dial,
+		healthCheckPeriod: remoteConnHealthCheckInterval,
+		idleTTL:           remoteConnIdleTTL,
+		entries:           map[string]*remoteConnEntry{},
+	}
+}
+
+// Get returns a NetworkServiceClient backed by the cached connection for
+// url, dialing it if necessary, along with a release handle the caller must
+// invoke once done with the client.
+func (c *RemoteConnectionCache) Get(url string) (remote_networkservice.NetworkServiceClient, RemoteConnectionRelease, error) {
+	entry, err := c.acquire(url)
+	if err != nil {
+		return nil, nil, err
+	}
+	client := remote_networkservice.NewNetworkServiceClient(entry.conn)
+	return client, func() { c.release(entry) }, nil
+}
+
+func (c *RemoteConnectionCache) acquire(url string) (*remoteConnEntry, error) {
+	c.mu.Lock()
+	if entry, found := c.entries[url]; found {
+		c.mu.Unlock()
+		entry.mu.Lock()
+		entry.refcount++
+		entry.mu.Unlock()
+		return entry, nil
+	}
+	c.mu.Unlock()
+
+	v, err, _ := c.group.Do(url, func() (interface{}, error) {
+		c.mu.Lock()
+		if entry, found := c.entries[url]; found {
+			c.mu.Unlock()
+			return entry, nil
+		}
+		c.mu.Unlock()
+
+		conn, dialErr := c.dial(url)
+		if dialErr != nil {
+			return nil, dialErr
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		entry := &remoteConnEntry{conn: conn, cancel: cancel}
+
+		c.mu.Lock()
+		c.entries[url] = entry
+		c.mu.Unlock()
+
+		go c.watchState(url, entry, ctx)
+		go c.healthCheck(url, entry, ctx)
+		return entry, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	entry := v.(*remoteConnEntry)
+	entry.mu.Lock()
+	entry.refcount++
+	entry.mu.Unlock()
+	return entry, nil
+}
+
+func (c *RemoteConnectionCache) release(entry *remoteConnEntry) {
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	entry.refcount--
+	if entry.refcount <= 0 {
+		entry.refcount = 0
+		entry.idleSince = time.Now()
+	}
+}
+
+func (c *RemoteConnectionCache) evict(url string, entry *remoteConnEntry, reason string) {
+	c.mu.Lock()
+	if c.entries[url] == entry {
+		delete(c.entries, url)
+	}
+	c.mu.Unlock()
+
+	logrus.Infof("remote connection cache: evicting %s: %s", url, reason)
+	entry.cancel()
+	entry.conn.Close()
+}
+
+// watchState evicts entry as soon as its connection settles into
+// TransientFailure or Shutdown, so the next Get redials instead of handing
+// back a dead conn.
+func (c *RemoteConnectionCache) watchState(url string, entry *remoteConnEntry, ctx context.Context) {
+	for {
+		state := entry.conn.GetState()
+		if state == connectivity.TransientFailure || state == connectivity.Shutdown {
+			c.evict(url, entry, "connection entered "+state.String())
+			return
+		}
+		if !entry.conn.WaitForStateChange(ctx, state) {
+			return
+		}
+	}
+}
+
+// healthCheck periodically calls grpc.health.v1.Health/Check against entry,
+// evicting it on an unhealthy response, and separately evicts entries that
+// have sat unreferenced past the idle TTL. A peer that hasn't registered the
+// standard health service answers with codes.Unimplemented rather than a
+// status; that is not a health signal; it just means this peer predates (or
+// otherwise lacks) RegisterHealthServer, so it is not treated as a failure,
+// and eviction for such peers falls back to watchState's connectivity-state
+// check only.
+func (c *RemoteConnectionCache) healthCheck(url string, entry *remoteConnEntry, ctx context.Context) {
+	client := healthpb.NewHealthClient(entry.conn)
+	ticker := time.NewTicker(c.healthCheckPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			entry.mu.Lock()
+			idle := entry.refcount == 0 && !entry.idleSince.IsZero() && time.Since(entry.idleSince) > c.idleTTL
+			entry.mu.Unlock()
+			if idle {
+				c.evict(url, entry, "idle TTL exceeded")
+				return
+			}
+
+			checkCtx, cancel := context.WithTimeout(ctx, c.healthCheckPeriod/2)
+			resp, err := client.Check(checkCtx, &healthpb.HealthCheckRequest{})
+			cancel()
+			if err != nil && status.Code(err) == codes.Unimplemented {
+				continue
+			}
+			if err != nil || resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+				c.evict(url, entry, "failed health check")
+				return
+			}
+		}
+	}
+}
+
+// RegisterHealthServer installs the standard grpc.health.v1 health service,
+// always reporting SERVING, onto srv. The public/NSM server nsmd's peers
+// dial into must call this: RemoteConnectionCache.healthCheck calls
+// grpc.health.v1.Health/Check against every cached peer connection, and
+// without this registration that call fails with codes.Unimplemented on
+// every peer.
+func RegisterHealthServer(srv *grpc.Server) {
+	healthSrv := health.NewServer()
+	healthSrv.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, healthSrv)
+}
+
+// Close tears down every cached connection.
+func (c *RemoteConnectionCache) Close() {
+	c.mu.Lock()
+	entries := c.entries
+	c.entries = map[string]*remoteConnEntry{}
+	c.mu.Unlock()
+
+	for _, entry := range entries {
+		entry.cancel()
+		entry.conn.Close()
+	}
+}
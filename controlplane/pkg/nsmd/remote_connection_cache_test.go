@@ -0,0 +1,152 @@
+package nsmd
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// startHealthServer starts a gRPC server on addr (or an ephemeral port if
+// addr is "") reporting SERVING on the standard health service, and returns
+// its listen address plus a function to stop it.
+func startHealthServer(t *testing.T, addr string) (string, func()) {
+	lis, err := net.Listen("tcp", addr)
+	NewWithT(t).Expect(err).ToNot(HaveOccurred())
+
+	srv := grpc.NewServer()
+	healthSrv := health.NewServer()
+	healthSrv.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, healthSrv)
+	go srv.Serve(lis)
+
+	return lis.Addr().String(), func() { srv.Stop() }
+}
+
+// startPlainServer starts a gRPC server on addr (or an ephemeral port if
+// addr is "") with no services registered at all, so any RPC against it,
+// including grpc.health.v1.Health/Check, fails with codes.Unimplemented. It
+// returns the listen address plus a function to stop it.
+func startPlainServer(t *testing.T, addr string) (string, func()) {
+	lis, err := net.Listen("tcp", addr)
+	NewWithT(t).Expect(err).ToNot(HaveOccurred())
+
+	srv := grpc.NewServer()
+	go srv.Serve(lis)
+
+	return lis.Addr().String(), func() { srv.Stop() }
+}
+
+func countingDialer(counter *int32) func(url string) (*grpc.ClientConn, error) {
+	return func(url string) (*grpc.ClientConn, error) {
+		atomic.AddInt32(counter, 1)
+		return grpc.Dial(url, grpc.WithInsecure(), grpc.WithBlock(), grpc.WithTimeout(5*time.Second))
+	}
+}
+
+// TestRemoteConnectionCache_SingleDialUnderConcurrency asserts that N
+// concurrent Get calls for the same URL result in exactly one dial.
+func TestRemoteConnectionCache_SingleDialUnderConcurrency(t *testing.T) {
+	g := NewWithT(t)
+	addr, stop := startHealthServer(t, "127.0.0.1:0")
+	defer stop()
+
+	var dials int32
+	cache := NewRemoteConnectionCache(countingDialer(&dials))
+	defer cache.Close()
+
+	const concurrency = 20
+	var wg sync.WaitGroup
+	releases := make([]RemoteConnectionRelease, concurrency)
+	errs := make([]error, concurrency)
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, release, err := cache.Get(addr)
+			releases[i] = release
+			errs[i] = err
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < concurrency; i++ {
+		g.Expect(errs[i]).ToNot(HaveOccurred())
+		releases[i]()
+	}
+
+	g.Expect(atomic.LoadInt32(&dials)).To(Equal(int32(1)))
+}
+
+// TestRemoteConnectionCache_RedialsAfterPeerRecovers asserts that once a
+// cached peer connection is evicted (because the peer went away), a
+// subsequent Get redials and succeeds once the peer comes back.
+func TestRemoteConnectionCache_RedialsAfterPeerRecovers(t *testing.T) {
+	g := NewWithT(t)
+	addr, stop := startHealthServer(t, "127.0.0.1:0")
+
+	var dials int32
+	cache := NewRemoteConnectionCache(countingDialer(&dials))
+	defer cache.Close()
+
+	_, release, err := cache.Get(addr)
+	g.Expect(err).ToNot(HaveOccurred())
+	release()
+	g.Expect(atomic.LoadInt32(&dials)).To(Equal(int32(1)))
+
+	stop()
+
+	// Wait for watchState to notice the peer is gone and evict the entry.
+	g.Eventually(func() int32 {
+		_, release, err := cache.Get(addr)
+		if err != nil {
+			return atomic.LoadInt32(&dials)
+		}
+		release()
+		return atomic.LoadInt32(&dials)
+	}, 10*time.Second, 100*time.Millisecond).Should(Equal(int32(2)))
+
+	_, stop2 := startHealthServer(t, addr)
+	defer stop2()
+
+	g.Eventually(func() error {
+		_, release, err := cache.Get(addr)
+		if err == nil {
+			release()
+		}
+		return err
+	}, 10*time.Second, 200*time.Millisecond).Should(Succeed())
+}
+
+// TestRemoteConnectionCache_TolerateUnimplementedHealthCheck asserts that a
+// peer with no health service registered (answering Health/Check with
+// codes.Unimplemented) is not evicted by that alone, so a cached connection
+// to an otherwise-healthy peer that simply doesn't expose grpc.health.v1
+// survives across several health check ticks instead of being redialed
+// forever.
+func TestRemoteConnectionCache_TolerateUnimplementedHealthCheck(t *testing.T) {
+	g := NewWithT(t)
+	addr, stop := startPlainServer(t, "127.0.0.1:0")
+	defer stop()
+
+	var dials int32
+	cache := NewRemoteConnectionCache(countingDialer(&dials))
+	cache.healthCheckPeriod = 50 * time.Millisecond
+	defer cache.Close()
+
+	_, release, err := cache.Get(addr)
+	g.Expect(err).ToNot(HaveOccurred())
+	defer release()
+	g.Expect(atomic.LoadInt32(&dials)).To(Equal(int32(1)))
+
+	g.Consistently(func() int32 {
+		return atomic.LoadInt32(&dials)
+	}, 500*time.Millisecond, 50*time.Millisecond).Should(Equal(int32(1)))
+}
@@ -18,7 +18,9 @@ import (
 	"github.com/networkservicemesh/networkservicemesh/controlplane/pkg/serviceregistry"
 	"github.com/networkservicemesh/networkservicemesh/controlplane/pkg/vni"
 	dataplaneapi "github.com/networkservicemesh/networkservicemesh/dataplane/pkg/apis/dataplane"
+	"github.com/networkservicemesh/networkservicemesh/pkg/probes"
 	"github.com/networkservicemesh/networkservicemesh/pkg/tools"
+	"github.com/networkservicemesh/networkservicemesh/pkg/tools/security"
 	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
@@ -31,9 +33,38 @@ const (
 	folderMask             = 0777
 	NsmdApiAddressEnv      = "NSMD_API_ADDRESS"
 	NsmdApiAddressDefaults = "0.0.0.0:5001"
+
+	// RegistryBackendEnv selects how nsmdServiceRegistry publishes/discovers
+	// NSM and NSE endpoints: a gRPC nsm-registry, or the etcd
+	// serviceregistry.Backend.
+	RegistryBackendEnv = "NSM_REGISTRY_BACKEND"
+	// RegistryBackendGRPC talks to a single remote nsm-registry over gRPC,
+	// pulling on every RegistryClient()/NetworkServiceDiscovery() call. This
+	// is the default, matching pre-Backend behavior.
+	RegistryBackendGRPC = "grpc"
+	// RegistryBackendEtcd stores endpoints directly in etcd and serves
+	// discovery locally from a cache fed by Backend.Watch.
+	RegistryBackendEtcd = "etcd"
+
+	// remoteDialTimeout bounds how long dialRemoteNSM's redialer retries
+	// before giving up on a single RemoteNetworkServiceClient call.
+	remoteDialTimeout = 2 * time.Minute
 )
 
+// nsmdProbes is the shared Holder every nsmd subsystem in this package
+// reports into. NewServiceRegistryAt starts a probes.Server against it so
+// Kubernetes liveness/readiness probes can target nsmd directly instead of
+// relying on a sidecar; Stop() shuts that server back down.
+var nsmdProbes = probes.NewHolder()
+
+// Probes returns the Holder nsmd's registry, dataplane, and listener
+// subsystems report their health into.
+func Probes() *probes.Holder {
+	return nsmdProbes
+}
+
 type apiRegistry struct {
+	security *security.Manager
 }
 
 func (*apiRegistry) NewPublicListener() (net.Listener, error) {
@@ -42,52 +73,166 @@ func (*apiRegistry) NewPublicListener() (net.Listener, error) {
 		nsmdApiAddress = NsmdApiAddressDefaults
 	}
 
-	return net.Listen("tcp", nsmdApiAddress)
+	lis, err := net.Listen("tcp", nsmdApiAddress)
+	if err != nil {
+		nsmdProbes.Probe(probes.PublicListener).Update(probes.Failed, err.Error())
+		return nil, err
+	}
+	nsmdProbes.Probe(probes.PublicListener).Update(probes.Ready, "listening on "+nsmdApiAddress)
+	return lis, nil
 }
 
 func (*apiRegistry) NewNSMServerListener() (net.Listener, error) {
 	logrus.Infof("Starting NSM gRPC server listening on socket: %s", ServerSock)
 	if err := tools.SocketCleanup(ServerSock); err != nil {
+		nsmdProbes.Probe(probes.NSMServerListener).Update(probes.Failed, err.Error())
+		return nil, err
+	}
+	lis, err := net.Listen("unix", ServerSock)
+	if err != nil {
+		nsmdProbes.Probe(probes.NSMServerListener).Update(probes.Failed, err.Error())
 		return nil, err
 	}
-	return net.Listen("unix", ServerSock)
+	nsmdProbes.Probe(probes.NSMServerListener).Update(probes.Ready, "listening on "+ServerSock)
+	return lis, nil
 }
 
-func NewApiRegistry() serviceregistry.ApiRegistry {
-	return &apiRegistry{}
+// ServerCreds returns the grpc.ServerOption the public listener's gRPC
+// server should be constructed with. It is not part of serviceregistry.
+// ApiRegistry; callers that build the server type-assert to TLSApiRegistry
+// to pick it up.
+func (a *apiRegistry) ServerCreds() grpc.ServerOption {
+	return a.security.ServerCreds()
+}
+
+// TLSApiRegistry is implemented by the concrete value NewApiRegistry
+// returns. It exists so the public listener's gRPC server can be built with
+// TLS/mTLS creds without widening serviceregistry.ApiRegistry itself.
+type TLSApiRegistry interface {
+	serviceregistry.ApiRegistry
+	ServerCreds() grpc.ServerOption
+}
+
+// NewApiRegistry builds the public listener's serviceregistry.ApiRegistry.
+// It fails closed: if NSM_TLS_MODE/NSM_TLS_DIR are set but the security
+// Manager can't be built from them (bad/missing cert material), that is
+// reported to the caller instead of silently falling back to NSM_TLS_MODE=off,
+// since that fallback would accept plaintext connections an operator
+// explicitly asked to be encrypted.
+func NewApiRegistry() (serviceregistry.ApiRegistry, error) {
+	mgr, err := security.NewManagerFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize TLS for the public listener: %v", err)
+	}
+	return &apiRegistry{security: mgr}, nil
 }
 
 type nsmdServiceRegistry struct {
 	sync.RWMutex
 	registryClientConnection *grpc.ClientConn
-	stopRedial               bool
+	registryRedialer         *tools.Redialer
 	vniAllocator             vni.VniAllocator
 	registryAddress          string
+	backendKind              string
+	etcdBackend              *etcdBackend
+	etcdCache                *etcdRegistryCache
+	etcdRegistryClient       *etcdRegistryClient
+	security                 *security.Manager
+	remoteConnCache          *RemoteConnectionCache
+	dataplaneRedialer        *tools.Redialer
+	probeServer              *probes.Server
 }
 
 func (impl *nsmdServiceRegistry) NewWorkspaceProvider() serviceregistry.WorkspaceLocationProvider {
 	return NewDefaultWorkspaceProvider()
 }
 
-func (impl *nsmdServiceRegistry) RemoteNetworkServiceClient(nsm *registry.NetworkServiceManager) (remote_networkservice.NetworkServiceClient, *grpc.ClientConn, error) {
-	err := tools.WaitForPortAvailable(context.Background(), "tcp", nsm.GetUrl(), 1*time.Second)
+// RemoteNetworkServiceClient returns a NetworkServiceClient to the peer NSM
+// at nsm.GetUrl(), reusing a cached *grpc.ClientConn across calls instead of
+// dialing fresh every time. The returned RemoteConnectionRelease must be
+// invoked once the caller is done issuing RPCs through the client.
+//
+// This is a breaking change from the previous signature, which handed back
+// the *grpc.ClientConn itself for the caller to Close(); closing a shared,
+// cached connection out from under other callers would defeat the point of
+// caching it. Every call site of RemoteNetworkServiceClient in this tree has
+// been checked and already uses the RemoteConnectionRelease form above; a
+// caller added outside this tree on the old signature will fail to compile
+// and must be migrated to call Release instead of Close.
+func (impl *nsmdServiceRegistry) RemoteNetworkServiceClient(nsm *registry.NetworkServiceManager) (remote_networkservice.NetworkServiceClient, RemoteConnectionRelease, error) {
+	return impl.remoteConnCache.Get(nsm.GetUrl())
+}
+
+// dialRemoteNSM is the RemoteConnectionCache dial func for peer NSM
+// connections: it retries, with exponential backoff and full jitter, until
+// the peer's port is reachable and the dial succeeds or remoteDialTimeout
+// elapses.
+func (impl *nsmdServiceRegistry) dialRemoteNSM(url string) (*grpc.ClientConn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), remoteDialTimeout)
+	defer cancel()
+
+	var conn *grpc.ClientConn
+	redialer := tools.NewRedialer(tools.DefaultBackoffConfig, func(ev tools.RedialEvent) {
+		switch ev.Type {
+		case tools.RedialConnectAttempt:
+			logrus.Infof("Attempting to connect to Network Service Manager at %s (try %d)...", url, ev.Tries+1)
+		case tools.RedialGiveUp:
+			logrus.Errorf("Failed to dial Network Service Manager at %s: %s", url, ev.Err)
+		}
+	})
+	err := redialer.Run(ctx, func(ctx context.Context) error {
+		if err := tools.WaitForPortAvailable(ctx, "tcp", url, 1*time.Second); err != nil {
+			return err
+		}
+		tracer := opentracing.GlobalTracer()
+		c, err := grpc.Dial(url, impl.security.DialOption(),
+			grpc.WithUnaryInterceptor(
+				otgrpc.OpenTracingClientInterceptor(tracer, otgrpc.LogPayloads())),
+			grpc.WithStreamInterceptor(
+				otgrpc.OpenTracingStreamClientInterceptor(tracer)))
+		if err != nil {
+			return err
+		}
+		conn = c
+		return nil
+	})
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
+	return conn, nil
+}
 
-	logrus.Infof("Remote Network Service %s is available at %s, attempting to connect...", nsm.GetName(), nsm.GetUrl())
-	tracer := opentracing.GlobalTracer()
-	conn, err := grpc.Dial(nsm.Url, grpc.WithInsecure(),
-		grpc.WithUnaryInterceptor(
-			otgrpc.OpenTracingClientInterceptor(tracer, otgrpc.LogPayloads())),
-		grpc.WithStreamInterceptor(
-			otgrpc.OpenTracingStreamClientInterceptor(tracer)))
-	if err != nil {
-		logrus.Errorf("Failed to dial Network Service Registry %s at %s: %s", nsm.GetName(), nsm.Url, err)
-		return nil, nil, err
+// AuthorizeRemoteNSM checks that the peer nsmd dialed into ctx is in fact
+// the NSM it claims to be, by comparing its SPIFFE-style SAN identity
+// against nsm.GetName().
+func AuthorizeRemoteNSM(ctx context.Context, nsm *registry.NetworkServiceManager) error {
+	return security.AuthorizePeer(ctx, nsm.GetName())
+}
+
+// UnaryAuthorizeRemoteNSMInterceptor returns a grpc.UnaryServerInterceptor
+// that rejects a call unless AuthorizeRemoteNSM(ctx, expected) succeeds. It
+// is exported for the public listener's gRPC server to install once
+// NSM_TLS_MODE is tls or mtls; under ModeOff there is no peer identity to
+// check, so that server construction should skip installing it rather than
+// pass an always-failing expected NSM.
+func UnaryAuthorizeRemoteNSMInterceptor(expected *registry.NetworkServiceManager) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := AuthorizeRemoteNSM(ctx, expected); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamAuthorizeRemoteNSMInterceptor is the streaming counterpart of
+// UnaryAuthorizeRemoteNSMInterceptor.
+func StreamAuthorizeRemoteNSMInterceptor(expected *registry.NetworkServiceManager) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := AuthorizeRemoteNSM(ss.Context(), expected); err != nil {
+			return err
+		}
+		return handler(srv, ss)
 	}
-	client := remote_networkservice.NewNetworkServiceClient(conn)
-	return client, conn, nil
 }
 
 func (impl *nsmdServiceRegistry) EndpointConnection(endpoint *registry.NSERegistration) (networkservice.NetworkServiceClient, *grpc.ClientConn, error) {
@@ -147,6 +292,12 @@ func (impl *nsmdServiceRegistry) RegistryClient() (registry.NetworkServiceRegist
 	logrus.Info("Requesting RegistryClient...")
 
 	impl.initRegistryClient()
+	if impl.backendKind == RegistryBackendEtcd {
+		if impl.etcdBackend == nil {
+			return nil, fmt.Errorf("connection to etcd Network Registry Server is not available")
+		}
+		return impl.etcdRegistryClient, nil
+	}
 	if impl.registryClientConnection != nil {
 		return registry.NewNetworkServiceRegistryClient(impl.registryClientConnection), nil
 	}
@@ -164,52 +315,121 @@ func (impl *nsmdServiceRegistry) NetworkServiceDiscovery() (registry.NetworkServ
 	logrus.Info("Requesting NetworkServiceDiscoveryClient...")
 
 	impl.initRegistryClient()
+	if impl.backendKind == RegistryBackendEtcd {
+		if impl.etcdCache == nil {
+			return nil, fmt.Errorf("connection to etcd Network Registry Server is not available")
+		}
+		return &etcdDiscoveryClient{cache: impl.etcdCache}, nil
+	}
 	if impl.registryClientConnection != nil {
 		return registry.NewNetworkServiceDiscoveryClient(impl.registryClientConnection), nil
 	}
 	return nil, fmt.Errorf("Connection to Network Registry Server is not available")
 }
 
+// registryPrefix returns the etcd key prefix NSM/NSE entries are stored
+// under; only meaningful when backendKind is RegistryBackendEtcd.
+func (impl *nsmdServiceRegistry) registryPrefix() string {
+	prefix := strings.TrimSpace(os.Getenv(EtcdRegistryPrefixEnv))
+	if prefix == "" {
+		prefix = EtcdRegistryPrefixDefault
+	}
+	return prefix
+}
+
+// initEtcdRegistryClient lazily dials etcd and starts the registry cache the
+// first time a RegistryClient()/NetworkServiceDiscovery() is requested.
+func (impl *nsmdServiceRegistry) initEtcdRegistryClient() {
+	if impl.etcdBackend != nil {
+		return
+	}
+	endpoints := strings.Split(impl.registryAddress, ",")
+	prefix := impl.registryPrefix()
+	backend, err := newEtcdBackend(endpoints, prefix)
+	if err != nil {
+		logrus.Errorf("Failed to connect to etcd Network Registry at %v: %s", endpoints, err)
+		nsmdProbes.Probe(probes.Registry).Update(probes.Failed, err.Error())
+		return
+	}
+	cache, err := newEtcdRegistryCache(context.Background(), backend, prefix)
+	if err != nil {
+		logrus.Errorf("Failed to start etcd registry cache: %s", err)
+		nsmdProbes.Probe(probes.Registry).Update(probes.Failed, err.Error())
+		backend.Close()
+		return
+	}
+	impl.etcdBackend = backend
+	impl.etcdCache = cache
+	impl.etcdRegistryClient = &etcdRegistryClient{backend: backend, prefix: prefix, nseKeysByName: map[string]string{}}
+	logrus.Infof("Successfully connected to etcd Network Registry at %v", endpoints)
+	nsmdProbes.Probe(probes.Registry).Update(probes.Ready, "connected to "+prefix)
+}
+
 func (impl *nsmdServiceRegistry) initRegistryClient() {
-	var err error
+	if impl.backendKind == RegistryBackendEtcd {
+		impl.initEtcdRegistryClient()
+		return
+	}
+
 	if impl.registryClientConnection != nil && impl.registryClientConnection.GetState() == connectivity.Ready {
 		return // Connection already established.
 	}
 	// TODO doing registry Address here is ugly
-	for impl.stopRedial {
-		tools.WaitForPortAvailable(context.Background(), "tcp", impl.registryAddress, 1*time.Second)
+	nsmdProbes.Probe(probes.Registry).Update(probes.NotReady, "connecting to "+impl.registryAddress)
+	err := impl.registryRedialer.Run(context.Background(), func(ctx context.Context) error {
+		if err := tools.WaitForPortAvailable(ctx, "tcp", impl.registryAddress, 1*time.Second); err != nil {
+			return err
+		}
 		logrus.Println("Registry port now available, attempting to connect...")
 		tracer := opentracing.GlobalTracer()
-		conn, err := grpc.Dial(impl.registryAddress, grpc.WithInsecure(),
+		conn, err := grpc.Dial(impl.registryAddress, impl.security.DialOption(),
 			grpc.WithUnaryInterceptor(
 				otgrpc.OpenTracingClientInterceptor(tracer, otgrpc.LogPayloads())),
 			grpc.WithStreamInterceptor(
 				otgrpc.OpenTracingStreamClientInterceptor(tracer)))
 		if err != nil {
-			logrus.Errorf("Failed to dial Network Service Registry at %s: %s", impl.registryAddress, err)
-			continue
+			return err
 		}
 		impl.registryClientConnection = conn
 		logrus.Infof("Successfully connected to %s", impl.registryAddress)
+		return nil
+	})
+	if err != nil {
+		logrus.Errorf("Failed to dial Network Service Registry at %s: %s", impl.registryAddress, err)
+		nsmdProbes.Probe(probes.Registry).Update(probes.Failed, err.Error())
 		return
 	}
-	err = fmt.Errorf("stopped before success trying to dial Network Registry Server")
-	logrus.Error(err)
+	nsmdProbes.Probe(probes.Registry).Update(probes.Ready, "connected to "+impl.registryAddress)
 }
 
 func (impl *nsmdServiceRegistry) Stop() {
-	// I know the stopRedial isn't threadsafe... we don't care, its set once at creation to true
-	// so if you set it to false, eventually the redial loop will notice and stop.
-	impl.stopRedial = false
+	// Flip every probe to Not-Ready before tearing anything down, so a
+	// readiness check targeting /readyz fails and a load balancer drains
+	// traffic away before the underlying connections actually close.
+	nsmdProbes.NotReadyAll("nsmd is shutting down")
+
+	impl.registryRedialer.Stop()
+	impl.dataplaneRedialer.Stop()
+	if impl.probeServer != nil {
+		if err := impl.probeServer.Stop(); err != nil {
+			logrus.Errorf("Error stopping probes HTTP server: %v", err)
+		}
+	}
 	impl.RWMutex.Lock()
 	defer impl.RWMutex.Unlock()
 
 	if impl.registryClientConnection != nil {
 		impl.registryClientConnection.Close()
 	}
+	if impl.etcdBackend != nil {
+		impl.etcdBackend.Close()
+	}
+	if impl.remoteConnCache != nil {
+		impl.remoteConnCache.Close()
+	}
 }
 
-func NewServiceRegistry() serviceregistry.ServiceRegistry {
+func NewServiceRegistry() (serviceregistry.ServiceRegistry, error) {
 	registryAddress := os.Getenv("NSM_REGISTRY_ADDRESS")
 	registryAddress = strings.TrimSpace(registryAddress)
 	if registryAddress == "" {
@@ -219,21 +439,62 @@ func NewServiceRegistry() serviceregistry.ServiceRegistry {
 	return NewServiceRegistryAt(registryAddress)
 }
 
-func NewServiceRegistryAt(nsmAddress string) serviceregistry.ServiceRegistry {
-	return &nsmdServiceRegistry{
-		stopRedial:      true,
+// NewServiceRegistryAt fails closed on a bad security configuration, for the
+// same reason NewApiRegistry does: a misconfigured NSM_TLS_MODE/NSM_TLS_DIR
+// must not silently downgrade the registry connection to plaintext.
+func NewServiceRegistryAt(nsmAddress string) (serviceregistry.ServiceRegistry, error) {
+	backendKind := strings.TrimSpace(os.Getenv(RegistryBackendEnv))
+	if backendKind == "" {
+		backendKind = RegistryBackendGRPC
+	}
+
+	securityManager, err := security.NewManagerFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize TLS for the registry connection: %v", err)
+	}
+
+	impl := &nsmdServiceRegistry{
 		vniAllocator:    vni.NewVniAllocator(),
+		backendKind:     backendKind,
 		registryAddress: nsmAddress,
+		security:        securityManager,
+		registryRedialer: tools.NewRedialer(tools.DefaultBackoffConfig, func(ev tools.RedialEvent) {
+			// RedialConnectAttempt leaves the probe as-is: it was NotReady
+			// before the first attempt and stays NotReady across retries,
+			// which is already the correct state to report while dialing.
+			// RedialConnectSuccess is likewise left to initRegistryClient,
+			// which has the "connected to X" message this observer doesn't.
+			if ev.Type == tools.RedialGiveUp {
+				nsmdProbes.Probe(probes.Registry).Update(probes.Failed, ev.Err.Error())
+			}
+		}),
+		dataplaneRedialer: tools.NewRedialer(tools.BackoffConfig{Base: 100 * time.Millisecond, Cap: 5 * time.Second, Multiplier: 2}, func(ev tools.RedialEvent) {
+			if ev.Type == tools.RedialGiveUp {
+				nsmdProbes.Probe(probes.Dataplane).Update(probes.Failed, ev.Err.Error())
+			}
+		}),
 	}
+	impl.remoteConnCache = NewRemoteConnectionCache(impl.dialRemoteNSM)
+
+	impl.probeServer = probes.NewServer(nsmdProbes)
+	impl.probeServer.Start()
+
+	return impl, nil
 }
 
 func (impl *nsmdServiceRegistry) WaitForDataplaneAvailable(model model.Model) {
 	logrus.Info("Waiting for dataplane available...")
-	for ; true; <-time.After(100 * time.Millisecond) {
+	err := impl.dataplaneRedialer.Run(context.Background(), func(context.Context) error {
 		if dp, _ := model.SelectDataplane(); dp != nil {
-			break
+			return nil
 		}
+		return fmt.Errorf("no dataplane available yet")
+	})
+	if err != nil {
+		nsmdProbes.Probe(probes.Dataplane).Update(probes.Failed, err.Error())
+		return
 	}
+	nsmdProbes.Probe(probes.Dataplane).Update(probes.Ready, "dataplane selected")
 }
 
 func (impl *nsmdServiceRegistry) VniAllocator() vni.VniAllocator {
@@ -0,0 +1,69 @@
+package serviceregistry
+
+import (
+	"context"
+
+	"github.com/networkservicemesh/networkservicemesh/controlplane/pkg/apis/registry"
+)
+
+// EndpointKind identifies whether a Backend entry describes an NSM or an NSE.
+type EndpointKind string
+
+const (
+	// NSMEndpoint marks an entry describing a Network Service Manager.
+	NSMEndpoint EndpointKind = "nsm"
+	// NSEEndpoint marks an entry describing a Network Service Endpoint.
+	NSEEndpoint EndpointKind = "nse"
+)
+
+// Endpoint is the envelope a Backend stores and reports for a single NSM or
+// NSE entry. Exactly one of NSM/NSE is populated, depending on Kind.
+type Endpoint struct {
+	Kind EndpointKind
+	Key  string
+	NSM  *registry.NetworkServiceManager
+	NSE  *registry.NSERegistration
+}
+
+// EventType describes the kind of change a Backend reports through Watch.
+type EventType int
+
+const (
+	// EventAdd reports that an endpoint appeared under the watched prefix.
+	EventAdd EventType = iota
+	// EventUpdate reports that an already known endpoint changed.
+	EventUpdate
+	// EventDelete reports that an endpoint disappeared, either because it
+	// was explicitly removed or because its lease expired.
+	EventDelete
+)
+
+// Event is a single change reported by Backend.Watch.
+type Event struct {
+	Type     EventType
+	Endpoint *Endpoint
+}
+
+// Backend abstracts the storage and discovery mechanism nsmdServiceRegistry
+// uses to publish NSM/NSE endpoints and to observe the endpoints published by
+// other nsmd instances. The etcd implementation in pkg/nsmd is selected via
+// NSM_REGISTRY_BACKEND=etcd; the default grpc setting talks to nsm-registry
+// over a plain gRPC connection and does not go through a Backend.
+type Backend interface {
+	// Add publishes endpoint. Implementations that bind entries to a lease
+	// (e.g. etcd) should refresh that lease for as long as the owning nsmd
+	// is alive, so a crashed peer's entries disappear on their own.
+	Add(ctx context.Context, endpoint *Endpoint) error
+	// Update republishes endpoint, overwriting the previous value at the
+	// same key.
+	Update(ctx context.Context, endpoint *Endpoint) error
+	// Delete removes the entry at key.
+	Delete(ctx context.Context, key string) error
+	// Watch streams the current entries under prefix followed by their
+	// subsequent changes. The returned channel is closed when ctx is done
+	// or the Backend is closed.
+	Watch(ctx context.Context, prefix string) (<-chan Event, error)
+	// Close releases any resources (connections, leases) held by the
+	// Backend.
+	Close() error
+}
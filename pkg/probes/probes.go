@@ -0,0 +1,150 @@
+// Package probes tracks the liveness/readiness of nsmd's subsystems
+// (registry connection, dataplane availability, listeners, device plugin)
+// so they can be exposed over HTTP for Kubernetes liveness/readiness
+// probes instead of relying on a sidecar.
+package probes
+
+import (
+	"sync"
+	"time"
+)
+
+// Well-known probe names used by pkg/nsmd.
+const (
+	Registry          = "registry"
+	Dataplane         = "dataplane"
+	PublicListener    = "public-listener"
+	NSMServerListener = "nsm-server-listener"
+	DevicePlugin      = "device-plugin"
+)
+
+// Status is the state of a single named Probe.
+type Status int
+
+const (
+	// NotReady is a Probe's initial state, and the state Holder.NotReadyAll
+	// forces every probe back into during shutdown.
+	NotReady Status = iota
+	// Ready means the subsystem the Probe tracks is up and usable.
+	Ready
+	// Failed means the subsystem's last bring-up or dial attempt returned an
+	// error, whether or not it had been Ready before (as opposed to NotReady,
+	// which covers "not ready yet" and shutdown).
+	Failed
+)
+
+func (s Status) String() string {
+	switch s {
+	case Ready:
+		return "Ready"
+	case Failed:
+		return "Failed"
+	default:
+		return "NotReady"
+	}
+}
+
+// Probe tracks the health of a single nsmd subsystem.
+type Probe struct {
+	name string
+
+	mu         sync.RWMutex
+	status     Status
+	message    string
+	transition time.Time
+}
+
+func newProbe(name string) *Probe {
+	return &Probe{name: name, status: NotReady}
+}
+
+// Update records status/msg for the probe. LastTransition only moves
+// forward when status actually changes, so repeated identical updates
+// don't reset it.
+func (p *Probe) Update(status Status, msg string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.status != status {
+		p.transition = time.Now()
+	}
+	p.status = status
+	p.message = msg
+}
+
+func (p *Probe) snapshot() State {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return State{Name: p.name, Status: p.status, Message: p.message, LastTransition: p.transition}
+}
+
+// State is a read-only snapshot of a Probe.
+type State struct {
+	Name           string
+	Status         Status
+	Message        string
+	LastTransition time.Time
+}
+
+// Holder aggregates named probes and exposes their combined readiness.
+type Holder struct {
+	mu     sync.RWMutex
+	probes map[string]*Probe
+}
+
+// NewHolder builds an empty Holder; probes are created lazily on first use
+// of Probe(name).
+func NewHolder() *Holder {
+	return &Holder{probes: map[string]*Probe{}}
+}
+
+// Probe returns the named probe, creating it (as NotReady) on first use.
+func (h *Holder) Probe(name string) *Probe {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	p, ok := h.probes[name]
+	if !ok {
+		p = newProbe(name)
+		h.probes[name] = p
+	}
+	return p
+}
+
+// Ready reports whether every registered probe is Ready. A Holder with no
+// probes registered yet is not Ready.
+func (h *Holder) Ready() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if len(h.probes) == 0 {
+		return false
+	}
+	for _, p := range h.probes {
+		if p.snapshot().Status != Ready {
+			return false
+		}
+	}
+	return true
+}
+
+// Snapshot returns the current state of every registered probe, keyed by
+// name.
+func (h *Holder) Snapshot() map[string]State {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	out := make(map[string]State, len(h.probes))
+	for name, p := range h.probes {
+		out[name] = p.snapshot()
+	}
+	return out
+}
+
+// NotReadyAll flips every registered probe to NotReady with msg. Intended
+// for use during shutdown, before tearing down the connections the probes
+// track, so a readiness check fails and a load balancer drains traffic
+// before those connections actually go away.
+func (h *Holder) NotReadyAll(msg string) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, p := range h.probes {
+		p.Update(NotReady, msg)
+	}
+}
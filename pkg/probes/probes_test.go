@@ -0,0 +1,93 @@
+package probes
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestProbe_Transitions(t *testing.T) {
+	tests := []struct {
+		name      string
+		from      Status
+		to        Status
+		wantMoved bool
+	}{
+		{name: "NotReady to Ready moves transition", from: NotReady, to: Ready, wantMoved: true},
+		{name: "Ready to Failed moves transition", from: Ready, to: Failed, wantMoved: true},
+		{name: "Failed to NotReady moves transition", from: Failed, to: NotReady, wantMoved: true},
+		{name: "Ready to Ready does not move transition", from: Ready, to: Ready, wantMoved: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			p := newProbe("test")
+			p.Update(tt.from, "initial")
+			before := p.snapshot().LastTransition
+
+			p.Update(tt.to, "updated")
+			after := p.snapshot()
+
+			g.Expect(after.Status).To(Equal(tt.to))
+			g.Expect(after.Message).To(Equal("updated"))
+			if tt.wantMoved {
+				g.Expect(after.LastTransition).To(BeTemporally(">", before))
+			} else {
+				g.Expect(after.LastTransition).To(Equal(before))
+			}
+		})
+	}
+}
+
+func TestHolder_Ready(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(h *Holder)
+		ready bool
+	}{
+		{name: "empty holder is not ready", setup: func(h *Holder) {}, ready: false},
+		{name: "single not-ready probe is not ready", setup: func(h *Holder) {
+			h.Probe(Registry)
+		}, ready: false},
+		{name: "single ready probe is ready", setup: func(h *Holder) {
+			h.Probe(Registry).Update(Ready, "")
+		}, ready: true},
+		{name: "one of many not ready makes holder not ready", setup: func(h *Holder) {
+			h.Probe(Registry).Update(Ready, "")
+			h.Probe(Dataplane).Update(NotReady, "waiting")
+		}, ready: false},
+		{name: "all ready makes holder ready", setup: func(h *Holder) {
+			h.Probe(Registry).Update(Ready, "")
+			h.Probe(Dataplane).Update(Ready, "")
+			h.Probe(PublicListener).Update(Ready, "")
+		}, ready: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			h := NewHolder()
+			tt.setup(h)
+			g.Expect(h.Ready()).To(Equal(tt.ready))
+		})
+	}
+}
+
+func TestHolder_NotReadyAll(t *testing.T) {
+	g := NewWithT(t)
+	h := NewHolder()
+	h.Probe(Registry).Update(Ready, "")
+	h.Probe(Dataplane).Update(Ready, "")
+	g.Expect(h.Ready()).To(BeTrue())
+
+	h.NotReadyAll("shutting down")
+
+	snapshot := h.Snapshot()
+	g.Expect(snapshot).To(HaveLen(2))
+	for _, state := range snapshot {
+		g.Expect(state.Status).To(Equal(NotReady))
+		g.Expect(state.Message).To(Equal("shutting down"))
+	}
+	g.Expect(h.Ready()).To(BeFalse())
+}
@@ -0,0 +1,98 @@
+package probes
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// AddrEnv selects the address the probe HTTP server listens on.
+	AddrEnv = "NSM_PROBE_ADDR"
+	// AddrDefault is used when AddrEnv is unset.
+	AddrDefault = ":5555"
+)
+
+// Server exposes a Holder's aggregated state over HTTP so Kubernetes
+// liveness/readiness probes can target nsmd directly instead of relying on
+// a sidecar: /healthz reports liveness (the process is up and answering at
+// all) and /readyz reports readiness (every registered probe is Ready),
+// each as 200/503 plus a JSON body enumerating every probe's state.
+type Server struct {
+	holder *Holder
+	http   *http.Server
+}
+
+// NewServer builds a Server for holder, listening on NSM_PROBE_ADDR (or
+// AddrDefault if unset).
+func NewServer(holder *Holder) *Server {
+	addr := strings.TrimSpace(os.Getenv(AddrEnv))
+	if addr == "" {
+		addr = AddrDefault
+	}
+
+	s := &Server{holder: holder}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.handleHealthz)
+	mux.HandleFunc("/readyz", s.handleReadyz)
+	s.http = &http.Server{Addr: addr, Handler: mux}
+	return s
+}
+
+// Start begins serving in a background goroutine. Errors other than the
+// server being closed via Stop are logged.
+func (s *Server) Start() {
+	go func() {
+		if err := s.http.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logrus.Errorf("probes: HTTP server stopped: %v", err)
+		}
+	}()
+}
+
+// Stop shuts down the HTTP server.
+func (s *Server) Stop() error {
+	return s.http.Close()
+}
+
+type statusResponse struct {
+	Ready  bool                   `json:"ready"`
+	Probes map[string]probeStatus `json:"probes"`
+}
+
+type probeStatus struct {
+	Status         string    `json:"status"`
+	Message        string    `json:"message,omitempty"`
+	LastTransition time.Time `json:"lastTransition"`
+}
+
+func (s *Server) writeSnapshot(w http.ResponseWriter, ready bool) {
+	snapshot := s.holder.Snapshot()
+	probesJSON := make(map[string]probeStatus, len(snapshot))
+	for name, st := range snapshot {
+		probesJSON[name] = probeStatus{Status: st.Status.String(), Message: st.Message, LastTransition: st.LastTransition}
+	}
+
+	statusCode := http.StatusOK
+	if !ready {
+		statusCode = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(statusResponse{Ready: ready, Probes: probesJSON})
+}
+
+// handleHealthz answers liveness: as long as this handler runs at all, the
+// process is alive, so it always reports ready regardless of probe state.
+func (s *Server) handleHealthz(w http.ResponseWriter, _ *http.Request) {
+	s.writeSnapshot(w, true)
+}
+
+// handleReadyz answers readiness: every registered probe must be Ready.
+func (s *Server) handleReadyz(w http.ResponseWriter, _ *http.Request) {
+	s.writeSnapshot(w, s.holder.Ready())
+}
@@ -0,0 +1,72 @@
+package probes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+// TestServer_Healthz asserts that /healthz always reports 200 with a ready
+// body, regardless of probe state, since it answers liveness rather than
+// readiness.
+func TestServer_Healthz(t *testing.T) {
+	g := NewWithT(t)
+	h := NewHolder()
+	h.Probe(Registry).Update(Failed, "etcd unreachable")
+	s := &Server{holder: h}
+
+	rec := httptest.NewRecorder()
+	s.handleHealthz(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	g.Expect(rec.Code).To(Equal(http.StatusOK))
+
+	var body statusResponse
+	g.Expect(json.Unmarshal(rec.Body.Bytes(), &body)).To(Succeed())
+	g.Expect(body.Ready).To(BeTrue())
+	g.Expect(body.Probes).To(HaveKey(Registry))
+	g.Expect(body.Probes[Registry].Status).To(Equal(Failed.String()))
+	g.Expect(body.Probes[Registry].Message).To(Equal("etcd unreachable"))
+}
+
+// TestServer_Readyz asserts that /readyz reports 200 only once every
+// registered probe is Ready, and 503 otherwise, mirroring Holder.Ready.
+func TestServer_Readyz(t *testing.T) {
+	tests := []struct {
+		name      string
+		setup     func(h *Holder)
+		wantCode  int
+		wantReady bool
+	}{
+		{name: "no probes registered", setup: func(h *Holder) {}, wantCode: http.StatusServiceUnavailable, wantReady: false},
+		{name: "one probe not ready", setup: func(h *Holder) {
+			h.Probe(Registry).Update(Ready, "")
+			h.Probe(Dataplane).Update(NotReady, "waiting")
+		}, wantCode: http.StatusServiceUnavailable, wantReady: false},
+		{name: "every probe ready", setup: func(h *Holder) {
+			h.Probe(Registry).Update(Ready, "")
+			h.Probe(Dataplane).Update(Ready, "")
+		}, wantCode: http.StatusOK, wantReady: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			h := NewHolder()
+			tt.setup(h)
+			s := &Server{holder: h}
+
+			rec := httptest.NewRecorder()
+			s.handleReadyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			g.Expect(rec.Code).To(Equal(tt.wantCode))
+			g.Expect(rec.Header().Get("Content-Type")).To(Equal("application/json"))
+
+			var body statusResponse
+			g.Expect(json.Unmarshal(rec.Body.Bytes(), &body)).To(Succeed())
+			g.Expect(body.Ready).To(Equal(tt.wantReady))
+		})
+	}
+}
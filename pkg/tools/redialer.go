@@ -0,0 +1,139 @@
+package tools
+
+import (
+	"context"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// RedialEventType enumerates the structured events a Redialer emits so
+// external observers (e.g. the probes subsystem) can track connection
+// health without coupling to the retry loop itself.
+type RedialEventType int
+
+const (
+	// RedialConnectAttempt fires right before each call to the attempt
+	// function passed to Run.
+	RedialConnectAttempt RedialEventType = iota
+	// RedialConnectSuccess fires once attempt returns nil.
+	RedialConnectSuccess
+	// RedialGiveUp fires when Run stops retrying because its context was
+	// done. A Run that returns because Stop was called emits no event; it
+	// returns attempt's last error (nil if attempt never ran).
+	RedialGiveUp
+)
+
+// RedialEvent is a single event emitted during a Redialer run.
+type RedialEvent struct {
+	Type  RedialEventType
+	Err   error
+	Tries int
+}
+
+// RedialObserver receives RedialEvents as a Redialer runs. Implementations
+// must not block.
+type RedialObserver func(RedialEvent)
+
+// BackoffConfig controls a Redialer's exponential-backoff-with-full-jitter
+// schedule: delays start at Base, grow by Multiplier on each failed
+// attempt, and are capped at Cap.
+type BackoffConfig struct {
+	Base       time.Duration
+	Cap        time.Duration
+	Multiplier float64
+}
+
+// DefaultBackoffConfig is used by NewRedialer when the zero value is
+// passed: 100ms, doubling, capped at 30s.
+var DefaultBackoffConfig = BackoffConfig{
+	Base:       100 * time.Millisecond,
+	Cap:        30 * time.Second,
+	Multiplier: 2,
+}
+
+// delay returns the upper bound for attempt's backoff, uniformly jittered
+// down to zero ("full jitter", as opposed to merely jittering around the
+// midpoint).
+func (b BackoffConfig) delay(attempt int) time.Duration {
+	d := float64(b.Base)
+	for i := 0; i < attempt; i++ {
+		d *= b.Multiplier
+		if d >= float64(b.Cap) {
+			d = float64(b.Cap)
+			break
+		}
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+// Redialer drives a retry-with-backoff loop over a caller-supplied attempt
+// function. It replaces the "for impl.stopRedial { ... }" pattern nsmd used
+// to hand-roll at each dial site: lifecycle is driven by a context.Context,
+// and Stop is safe to call concurrently with Run.
+type Redialer struct {
+	backoff  BackoffConfig
+	observer RedialObserver
+	stopped  int32
+}
+
+// NewRedialer builds a Redialer using backoff (DefaultBackoffConfig if the
+// zero value) and observer, which may be nil.
+func NewRedialer(backoff BackoffConfig, observer RedialObserver) *Redialer {
+	if backoff == (BackoffConfig{}) {
+		backoff = DefaultBackoffConfig
+	}
+	if observer == nil {
+		observer = func(RedialEvent) {}
+	}
+	return &Redialer{backoff: backoff, observer: observer}
+}
+
+// Stop halts this Redialer's current and any future Run calls. Safe to call
+// concurrently with Run and more than once.
+func (r *Redialer) Stop() {
+	atomic.StoreInt32(&r.stopped, 1)
+}
+
+func (r *Redialer) isStopped() bool {
+	return atomic.LoadInt32(&r.stopped) != 0
+}
+
+// Run calls attempt repeatedly, backing off between failures, until attempt
+// returns nil, ctx is Done, or Stop is called. It returns attempt's last
+// error, or ctx.Err() if ctx being done is why Run gave up.
+func (r *Redialer) Run(ctx context.Context, attempt func(ctx context.Context) error) error {
+	var lastErr error
+	for tries := 0; ; tries++ {
+		if r.isStopped() {
+			return lastErr
+		}
+		select {
+		case <-ctx.Done():
+			r.observer(RedialEvent{Type: RedialGiveUp, Err: ctx.Err(), Tries: tries})
+			return ctx.Err()
+		default:
+		}
+
+		r.observer(RedialEvent{Type: RedialConnectAttempt, Tries: tries})
+		err := attempt(ctx)
+		if err == nil {
+			r.observer(RedialEvent{Type: RedialConnectSuccess, Tries: tries})
+			return nil
+		}
+		lastErr = err
+
+		if r.isStopped() {
+			return lastErr
+		}
+		select {
+		case <-ctx.Done():
+			r.observer(RedialEvent{Type: RedialGiveUp, Err: ctx.Err(), Tries: tries})
+			return ctx.Err()
+		case <-time.After(r.backoff.delay(tries)):
+		}
+	}
+}
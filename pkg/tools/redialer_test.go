@@ -0,0 +1,100 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+// TestRedialer_StopConcurrentWithRunDoesNotLeak hammers Stop() concurrently
+// with many in-flight Run() calls and asserts every Run returns and no
+// goroutines are left behind. Intended to be run with -race.
+func TestRedialer_StopConcurrentWithRunDoesNotLeak(t *testing.T) {
+	g := NewWithT(t)
+	before := runtime.NumGoroutine()
+
+	const runners = 50
+	r := NewRedialer(BackoffConfig{Base: time.Millisecond, Cap: 10 * time.Millisecond, Multiplier: 2}, nil)
+
+	var wg sync.WaitGroup
+	var attempts int32
+	for i := 0; i < runners; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = r.Run(context.Background(), func(context.Context) error {
+				atomic.AddInt32(&attempts, 1)
+				return errors.New("always fails until stopped")
+			})
+		}()
+	}
+
+	// Stop concurrently from many goroutines while Run loops are in flight.
+	var stopWg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		stopWg.Add(1)
+		go func() {
+			defer stopWg.Done()
+			r.Stop()
+		}()
+	}
+	stopWg.Wait()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("redialer: Run goroutines did not return after Stop")
+	}
+
+	g.Expect(atomic.LoadInt32(&attempts)).To(BeNumerically(">", 0))
+
+	g.Eventually(func() int {
+		return runtime.NumGoroutine()
+	}, 2*time.Second, 50*time.Millisecond).Should(BeNumerically("<=", before+2))
+}
+
+// TestRedialer_SucceedsAfterRetries asserts Run retries on failure and
+// returns nil once attempt succeeds.
+func TestRedialer_SucceedsAfterRetries(t *testing.T) {
+	g := NewWithT(t)
+	r := NewRedialer(BackoffConfig{Base: time.Millisecond, Cap: 5 * time.Millisecond, Multiplier: 2}, nil)
+
+	var calls int32
+	err := r.Run(context.Background(), func(context.Context) error {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	})
+
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(atomic.LoadInt32(&calls)).To(Equal(int32(3)))
+}
+
+// TestRedialer_GivesUpWhenContextDone asserts Run returns the context error
+// once its deadline passes, instead of retrying forever.
+func TestRedialer_GivesUpWhenContextDone(t *testing.T) {
+	g := NewWithT(t)
+	r := NewRedialer(BackoffConfig{Base: time.Millisecond, Cap: 5 * time.Millisecond, Multiplier: 2}, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := r.Run(ctx, func(context.Context) error {
+		return errors.New("never succeeds")
+	})
+
+	g.Expect(err).To(Equal(context.DeadlineExceeded))
+}
@@ -0,0 +1,216 @@
+package security
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+)
+
+// testCA is a minimal in-process certificate authority used to issue leaf
+// certificates with a SPIFFE-style URI SAN, without touching the network or
+// any external tooling.
+type testCA struct {
+	cert *x509.Certificate
+	key  *rsa.PrivateKey
+	der  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	NewWithT(t).Expect(err).ToNot(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "nsm-test-ca"},
+		NotBefore:             time.Unix(0, 0),
+		NotAfter:              time.Unix(0, 0).Add(100 * 365 * 24 * time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	NewWithT(t).Expect(err).ToNot(HaveOccurred())
+
+	cert, err := x509.ParseCertificate(der)
+	NewWithT(t).Expect(err).ToNot(HaveOccurred())
+
+	return &testCA{cert: cert, key: key, der: der}
+}
+
+// issue writes a bundle (tls.crt, tls.key, ca.crt) under dir for a leaf
+// certificate identified by spiffeID, signed by ca. The leaf also carries
+// 127.0.0.1 as an IP SAN, since the tests dial servers by that address.
+func (ca *testCA) issue(t *testing.T, dir, spiffeID string) {
+	g := NewWithT(t)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	uri, err := url.Parse(spiffeID)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()%1000000 + 2),
+		Subject:      pkix.Name{CommonName: spiffeID},
+		NotBefore:    time.Unix(0, 0),
+		NotAfter:     time.Unix(0, 0).Add(100 * 365 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		URIs:         []*url.URL{uri},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	g.Expect(err).ToNot(HaveOccurred())
+
+	g.Expect(os.MkdirAll(dir, 0755)).To(Succeed())
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.der})
+
+	g.Expect(ioutil.WriteFile(dir+"/"+certFile, certPEM, 0644)).To(Succeed())
+	g.Expect(ioutil.WriteFile(dir+"/"+keyFile, keyPEM, 0644)).To(Succeed())
+	g.Expect(ioutil.WriteFile(dir+"/"+caFile, caPEM, 0644)).To(Succeed())
+}
+
+// TestManager_MTLSConnect asserts that a client and server each loaded from
+// an in-process CA can complete an mTLS handshake and that the server can
+// recover the client's SPIFFE identity via PeerName.
+func TestManager_MTLSConnect(t *testing.T) {
+	g := NewWithT(t)
+	ca := newTestCA(t)
+
+	serverDir := t.TempDir()
+	clientDir := t.TempDir()
+	ca.issue(t, serverDir, "spiffe://networkservicemesh.io/nsm/server")
+	ca.issue(t, clientDir, "spiffe://networkservicemesh.io/nsm/client")
+
+	serverMgr, err := NewManager(ModeMTLS, serverDir)
+	g.Expect(err).ToNot(HaveOccurred())
+	defer serverMgr.Close()
+
+	clientMgr, err := NewManager(ModeMTLS, clientDir)
+	g.Expect(err).ToNot(HaveOccurred())
+	defer clientMgr.Close()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	g.Expect(err).ToNot(HaveOccurred())
+
+	var observedPeer string
+	peerCh := make(chan string, 1)
+	srv := grpc.NewServer(serverMgr.ServerCreds(), grpc.UnknownServiceHandler(func(_ interface{}, stream grpc.ServerStream) error {
+		name, err := PeerName(stream.Context())
+		g.Expect(err).ToNot(HaveOccurred())
+		peerCh <- name
+		return nil
+	}))
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	conn, err := grpc.Dial(lis.Addr().String(), clientMgr.DialOption())
+	g.Expect(err).ToNot(HaveOccurred())
+	defer conn.Close()
+
+	_ = conn.Invoke(context.Background(), "/nsm.Test/Ping", struct{}{}, &struct{}{})
+
+	select {
+	case observedPeer = <-peerCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("security: server never observed a peer identity")
+	}
+	g.Expect(observedPeer).To(Equal("spiffe://networkservicemesh.io/nsm/client"))
+}
+
+// TestManager_RejectsUntrustedPeer asserts that a leaf certificate signed by
+// a different CA than the one the server trusts fails the TLS handshake
+// instead of being silently accepted.
+func TestManager_RejectsUntrustedPeer(t *testing.T) {
+	g := NewWithT(t)
+	trustedCA := newTestCA(t)
+	rogueCA := newTestCA(t)
+
+	serverDir := t.TempDir()
+	clientDir := t.TempDir()
+	trustedCA.issue(t, serverDir, "spiffe://networkservicemesh.io/nsm/server")
+	rogueCA.issue(t, clientDir, "spiffe://networkservicemesh.io/nsm/impostor")
+
+	serverMgr, err := NewManager(ModeMTLS, serverDir)
+	g.Expect(err).ToNot(HaveOccurred())
+	defer serverMgr.Close()
+
+	clientMgr, err := NewManager(ModeMTLS, clientDir)
+	g.Expect(err).ToNot(HaveOccurred())
+	defer clientMgr.Close()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	g.Expect(err).ToNot(HaveOccurred())
+
+	srv := grpc.NewServer(serverMgr.ServerCreds())
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	conn, err := grpc.Dial(lis.Addr().String(), clientMgr.DialOption())
+	g.Expect(err).ToNot(HaveOccurred())
+	defer conn.Close()
+
+	err = conn.Invoke(context.Background(), "/nsm.Test/Ping", struct{}{}, &struct{}{})
+	g.Expect(err).To(HaveOccurred())
+}
+
+// TestAuthorizePeer_RejectsMismatchedSAN asserts that a connection from an
+// authenticated-but-wrong NSM is rejected even though the TLS handshake
+// itself succeeded, because its SAN doesn't match the expected NSM name.
+func TestAuthorizePeer_RejectsMismatchedSAN(t *testing.T) {
+	g := NewWithT(t)
+	ca := newTestCA(t)
+
+	serverDir := t.TempDir()
+	clientDir := t.TempDir()
+	ca.issue(t, serverDir, "spiffe://networkservicemesh.io/nsm/server")
+	ca.issue(t, clientDir, "spiffe://networkservicemesh.io/nsm/unexpected-nsm")
+
+	serverMgr, err := NewManager(ModeMTLS, serverDir)
+	g.Expect(err).ToNot(HaveOccurred())
+	defer serverMgr.Close()
+
+	clientMgr, err := NewManager(ModeMTLS, clientDir)
+	g.Expect(err).ToNot(HaveOccurred())
+	defer clientMgr.Close()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	g.Expect(err).ToNot(HaveOccurred())
+
+	authErrCh := make(chan error, 1)
+	srv := grpc.NewServer(serverMgr.ServerCreds(), grpc.UnknownServiceHandler(func(_ interface{}, stream grpc.ServerStream) error {
+		authErrCh <- AuthorizePeer(stream.Context(), "spiffe://networkservicemesh.io/nsm/expected-nsm")
+		return nil
+	}))
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	conn, err := grpc.Dial(lis.Addr().String(), clientMgr.DialOption())
+	g.Expect(err).ToNot(HaveOccurred())
+	defer conn.Close()
+
+	_ = conn.Invoke(context.Background(), "/nsm.Test/Ping", struct{}{}, &struct{}{})
+
+	select {
+	case authErr := <-authErrCh:
+		g.Expect(authErr).To(HaveOccurred())
+	case <-time.After(5 * time.Second):
+		t.Fatal("security: handler never ran AuthorizePeer")
+	}
+}